@@ -33,30 +33,69 @@ const (
 	anonymousField = "_"
 )
 
-// CodeGenerator creates following struct methods
-//   `IsSet() bool`
-//   `Reset()`
-//   `validate() error`
-// on all exported and anonymous structs that are referenced
-// by at least one of the root types
+// MethodPlugin generates one struct method (or a small family of related
+// methods) for a single structType. The built-in plugins produce IsSet,
+// Reset and validate; third-party plugins can be registered with
+// NewCodeGenerator to add further generated methods (DeepCopy, Diff,
+// Sanitize, ...) without touching CodeGenerator itself.
+type MethodPlugin interface {
+	// Name identifies the plugin and is used to annotate errors returned
+	// from Generate.
+	Name() string
+	// Generate writes the method(s) for structTyp to w. lookup resolves a
+	// types.Type to its parsed structType, mirroring
+	// CodeGenerator.customStruct, so a plugin can recurse into nested
+	// struct fields the same way the core generator does.
+	Generate(w io.Writer, structTyp structType, key string, lookup func(types.Type) (structType, bool)) error
+}
+
+// PluginContext bundles the helpers built-in plugins rely on beyond struct
+// lookup: deriving JSON wire names and flattening dotted field keys for
+// error messages. It is handed to plugins at construction time so they
+// don't need access to CodeGenerator's unexported state.
+type PluginContext struct {
+	JSONName    func(f structField) string
+	FlattenName func(key string, f structField) string
+}
+
+type validationGenerator func(io.Writer, []structField, structField, bool) error
+
+// CodeGenerator creates struct methods for all exported and anonymous structs
+// that are referenced by at least one of the root types. Which methods get
+// created is entirely driven by the configured plugins.
 type CodeGenerator struct {
 	buf      bytes.Buffer
 	parsed   *Parsed
 	rootObjs []structType
+	plugins  []MethodPlugin
 
 	// keep track of already processed types in case one type is
 	// referenced multiple times
 	processedTypes map[string]struct{}
 }
 
-type validationGenerator func(io.Writer, []structField, structField, bool) error
+// SetResetPolicy configures how the generated Reset() methods handle slice
+// and map backing memory; see ResetPolicy. It must be called before
+// Generate, and has no effect if custom plugins were supplied to
+// NewCodeGenerator that don't include the built-in reset plugin.
+func (g *CodeGenerator) SetResetPolicy(policy ResetPolicy) {
+	for _, plugin := range g.plugins {
+		if rp, ok := plugin.(*resetPlugin); ok {
+			rp.policy = policy
+		}
+	}
+}
 
-// NewCodeGenerator takes an importPath and the package name for which
-// the type definitions should be loaded.
-// The nullableTypePath is used to implement validation rules specific to types
-// of the nullable package. The generator creates methods only for types referenced
-// directly or indirectly by any of the root types.
-func NewCodeGenerator(parsed *Parsed, rootTypes []string) (*CodeGenerator, error) {
+// NewCodeGenerator takes the parsed type definitions and the root types for
+// which methods should be generated. plugins determines which methods are
+// emitted per struct, in order; a nil or empty slice falls back to
+// DefaultPlugins (IsSet, Reset, validate), so existing callers see no change
+// in behavior. Plugins that generate a method a type may already have a
+// hand-written implementation of (e.g. NewUnmarshalJSONPlugin,
+// NewDeepCopyPlugin) are opt-in and must be appended to DefaultPlugins (or a
+// custom plugin slice) explicitly. The generator creates methods only for
+// types referenced directly or indirectly by any of the root types.
+func NewCodeGenerator(parsed *Parsed, rootTypes []string, plugins []MethodPlugin) (*CodeGenerator, error) {
 	g := CodeGenerator{
 		parsed:         parsed,
 		rootObjs:       make([]structType, len(rootTypes)),
@@ -69,27 +108,68 @@ func NewCodeGenerator(parsed *Parsed, rootTypes []string) (*CodeGenerator, error
 		}
 		g.rootObjs[i] = rootStruct
 	}
+	if len(plugins) == 0 {
+		plugins = g.DefaultPlugins()
+	}
+	g.plugins = plugins
 	return &g, nil
 }
 
+// NewPluginContext builds the PluginContext DefaultPlugins wires into the
+// built-in plugins; exported so callers adding opt-in plugins (e.g.
+// NewUnmarshalJSONPlugin) alongside DefaultPlugins can share the same
+// helpers instead of re-deriving them.
+func NewPluginContext() *PluginContext {
+	return &PluginContext{
+		JSONName:    jsonName,
+		FlattenName: flattenName,
+	}
+}
+
+// DefaultPlugins returns the built-in IsSet, Reset and validate plugins,
+// wired up with this generator's root types. Plugins for methods a type may
+// already have a hand-written implementation of (NewUnmarshalJSONPlugin,
+// NewDeepCopyPlugin) are not included here; append them explicitly when
+// constructing the plugin slice passed to NewCodeGenerator.
+func (g *CodeGenerator) DefaultPlugins() []MethodPlugin {
+	ctx := NewPluginContext()
+	return []MethodPlugin{
+		&isSetPlugin{},
+		&resetPlugin{},
+		&validationPlugin{ctx: ctx, rootObjs: g.rootObjs},
+	}
+}
+
+// hasUnmarshalJSONPlugin reports whether an unmarshalJSONPlugin is among
+// g.plugins, so Generate knows whether to emit its runtime support code
+// (the "bytes" import and the decodeDelim/decodeObjectKey helpers).
+func (g *CodeGenerator) hasUnmarshalJSONPlugin() bool {
+	for _, p := range g.plugins {
+		if _, ok := p.(*unmarshalJSONPlugin); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // Generate generates the code for given root structs and all
 // dependencies and returns it as bytes.Buffer
 func (g *CodeGenerator) Generate() (bytes.Buffer, error) {
+	imports := []string{`"fmt"`, `"encoding/json"`, `"github.com/pkg/errors"`, `"regexp"`, `"unicode/utf8"`}
+	if g.hasUnmarshalJSONPlugin() {
+		imports = append([]string{`"bytes"`}, imports...)
+	}
 	fmt.Fprintf(&g.buf, `
 // Code generated by "modeldecoder/generator". DO NOT EDIT.
 
 package %s
 
 import (
-	"fmt"
-	"encoding/json"
-	"github.com/pkg/errors"
-	"regexp"
-	"unicode/utf8"
+	%s
 )
 
 var (
-`[1:], g.parsed.pkgName)
+`[1:], g.parsed.pkgName, strings.Join(imports, "\n\t"))
 	for _, name := range sortKeys(g.parsed.patternVariables) {
 		fmt.Fprintf(&g.buf, `
 %sRegexp = regexp.MustCompile(%s)
@@ -98,6 +178,9 @@ var (
 	fmt.Fprint(&g.buf, `
 )
 `[1:])
+	if g.hasUnmarshalJSONPlugin() {
+		fmt.Fprint(&g.buf, unmarshalRuntimeSupport)
+	}
 
 	// run generator code
 	for _, rootObj := range g.rootObjs {
@@ -117,14 +200,10 @@ func (g *CodeGenerator) generate(st structType, key string) error {
 		return nil
 	}
 	g.processedTypes[st.name] = struct{}{}
-	if err := g.generateIsSet(st, key); err != nil {
-		return err
-	}
-	if err := g.generateReset(st, key); err != nil {
-		return err
-	}
-	if err := g.generateValidation(st, key); err != nil {
-		return err
+	for _, plugin := range g.plugins {
+		if err := plugin.Generate(&g.buf, st, key, g.customStruct); err != nil {
+			return errors.Wrap(err, plugin.Name())
+		}
 	}
 	if key != "" {
 		key += "."
@@ -148,14 +227,18 @@ func (g *CodeGenerator) generate(st structType, key string) error {
 	return nil
 }
 
-// generateIsSet creates `IsSet` methods for struct fields,
-// indicating if the fields have been initialized;
-// it only considers exported fields, aligned with standard marshal behavior
-func (g *CodeGenerator) generateIsSet(structTyp structType, key string) error {
+// isSetPlugin generates the `IsSet` method, reporting whether any exported
+// field of the struct has been initialized; it only considers exported
+// fields, aligned with standard marshal behavior.
+type isSetPlugin struct{}
+
+func (p *isSetPlugin) Name() string { return "isset" }
+
+func (p *isSetPlugin) Generate(w io.Writer, structTyp structType, key string, lookup func(types.Type) (structType, bool)) error {
 	if len(structTyp.fields) == 0 {
 		return fmt.Errorf("unhandled struct %s (does not have any exported fields)", structTyp.name)
 	}
-	fmt.Fprintf(&g.buf, `
+	fmt.Fprintf(w, `
 func (val *%s) IsSet() bool {
 	return`, structTyp.name)
 	if key != "" {
@@ -169,25 +252,66 @@ func (val *%s) IsSet() bool {
 		}
 		switch t := f.Type().Underlying().(type) {
 		case *types.Slice, *types.Map:
-			fmt.Fprintf(&g.buf, `%s len(val.%s) > 0`, prefix, f.Name())
+			fmt.Fprintf(w, `%s len(val.%s) > 0`, prefix, f.Name())
 		case *types.Struct:
-			fmt.Fprintf(&g.buf, `%s val.%s.IsSet()`, prefix, f.Name())
+			fmt.Fprintf(w, `%s val.%s.IsSet()`, prefix, f.Name())
 		default:
 			return fmt.Errorf("unhandled type %T for IsSet() for '%s%s'", t, key, jsonName(f))
 		}
 		prefix = ` ||`
 	}
-	fmt.Fprint(&g.buf, `
+	fmt.Fprint(w, `
 }
 `)
 	return nil
 }
 
-// generateReset creates `Reset` methods for struct fields setting them to
-// their zero values or calling their `Reset` methods
-// it only considers exported fields
-func (g *CodeGenerator) generateReset(structTyp structType, key string) error {
-	fmt.Fprintf(&g.buf, `
+// resetMode selects how a resetPlugin handles a field's backing
+// allocation; see ResetPolicy.
+type resetMode int
+
+const (
+	retainCapacity resetMode = iota
+	releaseAboveThreshold
+	alwaysRelease
+)
+
+// ResetPolicy controls how generated Reset() methods handle slice and map
+// backing memory. The zero value is equivalent to RetainCapacity(), matching
+// the generator's original behavior.
+type ResetPolicy struct {
+	mode      resetMode
+	threshold int
+}
+
+// RetainCapacity keeps a field's backing array/map allocation across Reset,
+// trading RAM for fewer allocations on the pooled object's next use.
+func RetainCapacity() ResetPolicy { return ResetPolicy{mode: retainCapacity} }
+
+// ReleaseAboveThreshold releases a slice or map's backing allocation when
+// its capacity (for slices) or length (for maps) exceeded n before the
+// reset, and retains it otherwise. This bounds the memory an outlier event
+// - e.g. one with a huge attributes map or spans slice - leaves behind in
+// the decoder pool.
+func ReleaseAboveThreshold(n int) ResetPolicy {
+	return ResetPolicy{mode: releaseAboveThreshold, threshold: n}
+}
+
+// AlwaysRelease always drops a field's backing allocation, trading
+// allocator churn for a hard bound on per-pooled-object memory.
+func AlwaysRelease() ResetPolicy { return ResetPolicy{mode: alwaysRelease} }
+
+// resetPlugin generates the `Reset` method, setting exported fields to their
+// zero values or calling their `Reset` methods; it only considers exported
+// fields.
+type resetPlugin struct {
+	policy ResetPolicy
+}
+
+func (p *resetPlugin) Name() string { return "reset" }
+
+func (p *resetPlugin) Generate(w io.Writer, structTyp structType, key string, lookup func(types.Type) (structType, bool)) error {
+	fmt.Fprintf(w, `
 func (val *%s) Reset() {
 `, structTyp.name)
 	if key != "" {
@@ -199,63 +323,120 @@ func (val *%s) Reset() {
 		}
 		switch t := f.Type().Underlying().(type) {
 		case *types.Slice:
-			// the slice len is set to zero, not returning the underlying
-			// memory to the garbage collector; when the size of slices differs
-			// this potentially leads to keeping more memory allocated than required;
-
-			// if slice type is a model struct,
-			// call its Reset() function
-			if _, ok := g.customStruct(t.Elem()); ok {
-				fmt.Fprintf(&g.buf, `
-for i := range val.%s{
-	val.%s[i].Reset()
-}
-`[1:], f.Name(), f.Name())
-			}
-			// then reset size of slice to 0
-			fmt.Fprintf(&g.buf, `
-val.%s = val.%s[:0]
-`[1:], f.Name(), f.Name())
+			_, recurse := lookup(t.Elem())
+			p.generateSliceClear(w, f.Name(), recurse)
 
 		case *types.Map:
-			// the map is cleared, not returning the underlying memory to
-			// the garbage collector; when map size differs this potentially
-			// leads to keeping more memory allocated than required
-			fmt.Fprintf(&g.buf, `
-for k := range val.%s {
-	delete(val.%s, k)
-}
-`[1:], f.Name(), f.Name())
+			p.generateMapClear(w, f.Name())
 
 		case *types.Struct:
-			fmt.Fprintf(&g.buf, `
+			fmt.Fprintf(w, `
 val.%s.Reset()
 `[1:], f.Name())
 		default:
 			return fmt.Errorf("unhandled type %T for Reset() for '%s%s'", t, key, jsonName(f))
 		}
 	}
-	fmt.Fprint(&g.buf, `
+	fmt.Fprint(w, `
 }
 `[1:])
 	return nil
 }
 
-// generateValidation creates `validate` methods for struct fields
-// it only considers exported and anonymous fields
-func (g *CodeGenerator) generateValidation(structTyp structType, key string) error {
-	fmt.Fprintf(&g.buf, `
+// generateSliceClear writes the statement(s) that clear a slice field
+// according to p.policy. RetainCapacity (the default) keeps the backing
+// array and just resets the length to zero, not returning the underlying
+// memory to the garbage collector; when the size of slices differs this
+// potentially leads to keeping more memory allocated than required.
+//
+// recurse reports whether the slice's element type has its own Reset()
+// method (i.e. it's a model struct, per CodeGenerator.generate's recursion).
+// That elementwise Reset() loop is only emitted where the backing array
+// survives the clear: when alwaysRelease drops it outright, or
+// releaseAboveThreshold's cap check decides to at runtime, there's nothing
+// left to call Reset() on, so running the loop first would just be wasted
+// work on exactly the outlier slices this policy exists to bound the cost of.
+func (p *resetPlugin) generateSliceClear(w io.Writer, field string, recurse bool) {
+	elemReset := ""
+	if recurse {
+		elemReset = fmt.Sprintf(`
+for i := range val.%s {
+	val.%s[i].Reset()
+}
+`[1:], field, field)
+	}
+	switch p.policy.mode {
+	case alwaysRelease:
+		fmt.Fprintf(w, `
+val.%s = nil
+`[1:], field)
+	case releaseAboveThreshold:
+		fmt.Fprintf(w, `
+if cap(val.%s) > %d {
+	val.%s = nil
+} else {
+	%sval.%s = val.%s[:0]
+}
+`[1:], field, p.policy.threshold, field, elemReset, field, field)
+	default:
+		fmt.Fprintf(w, `
+%sval.%s = val.%s[:0]
+`[1:], elemReset, field, field)
+	}
+}
+
+// generateMapClear writes the statement(s) that clear a map field according
+// to p.policy. RetainCapacity (the default) clears the map in place,
+// not returning the underlying memory to the garbage collector; when map
+// size differs this potentially leads to keeping more memory allocated
+// than required.
+func (p *resetPlugin) generateMapClear(w io.Writer, field string) {
+	switch p.policy.mode {
+	case alwaysRelease:
+		fmt.Fprintf(w, `
+val.%s = nil
+`[1:], field)
+	case releaseAboveThreshold:
+		fmt.Fprintf(w, `
+if len(val.%s) > %d {
+	val.%s = nil
+} else {
+	for k := range val.%s {
+		delete(val.%s, k)
+	}
+}
+`[1:], field, p.policy.threshold, field, field, field)
+	default:
+		fmt.Fprintf(w, `
+for k := range val.%s {
+	delete(val.%s, k)
+}
+`[1:], field, field)
+	}
+}
+
+// validationPlugin generates the `validate` method; it only considers
+// exported and anonymous fields.
+type validationPlugin struct {
+	ctx      *PluginContext
+	rootObjs []structType
+}
+
+func (p *validationPlugin) Name() string { return "validate" }
+
+func (p *validationPlugin) Generate(w io.Writer, structTyp structType, key string, lookup func(types.Type) (structType, bool)) error {
+	fmt.Fprintf(w, `
 func (val *%s) validate() error {
 `, structTyp.name)
 	var isRoot bool
-	for _, rootObjs := range g.rootObjs {
+	for _, rootObjs := range p.rootObjs {
 		if structTyp.name == rootObjs.name {
 			isRoot = true
 			break
 		}
 	}
 	if !isRoot {
-		fmt.Fprint(&g.buf, `
+		fmt.Fprint(w, `
 if !val.IsSet() {
 	return nil
 }
@@ -287,22 +468,22 @@ if !val.IsSet() {
 			switch t := f.Type().Underlying().(type) {
 			case *types.Slice:
 				validation = generateSliceValidation
-				_, custom = g.customStruct(t.Elem())
+				_, custom = lookup(t.Elem())
 			case *types.Map:
 				validation = generateMapValidation
-				_, custom = g.customStruct(t.Elem())
+				_, custom = lookup(t.Elem())
 			case *types.Struct:
 				validation = generateStructValidation
-				_, custom = g.customStruct(f.Type())
+				_, custom = lookup(f.Type())
 			default:
-				return errors.Wrap(fmt.Errorf("unhandled type %T", t), flattenName(key, f))
+				return errors.Wrap(fmt.Errorf("unhandled type %T", t), p.ctx.FlattenName(key, f))
 			}
 		}
-		if err := validation(&g.buf, structTyp.fields, f, custom); err != nil {
-			return errors.Wrap(err, flattenName(key, f))
+		if err := validation(w, structTyp.fields, f, custom); err != nil {
+			return errors.Wrap(err, p.ctx.FlattenName(key, f))
 		}
 	}
-	fmt.Fprint(&g.buf, `
+	fmt.Fprint(w, `
 return nil
 }
 `[1:])
@@ -347,4 +528,4 @@ func sortKeys(input map[string]string) []string {
 	}
 	keys.Sort()
 	return keys
-}
\ No newline at end of file
+}
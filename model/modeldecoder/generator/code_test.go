@@ -0,0 +1,185 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package generator
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetPluginSliceClear(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		policy  ResetPolicy
+		recurse bool
+		want    []string
+		avoid   []string
+	}{
+		{
+			name:   "retain capacity is the zero value",
+			policy: ResetPolicy{},
+			want:   []string{"val.Spans = val.Spans[:0]"},
+			avoid:  []string{"nil", "cap(", "Reset()"},
+		},
+		{
+			name:    "retain capacity with recursive element",
+			policy:  RetainCapacity(),
+			recurse: true,
+			want:    []string{"for i := range val.Spans", "val.Spans[i].Reset()", "val.Spans = val.Spans[:0]"},
+			avoid:   []string{"nil", "cap("},
+		},
+		{
+			name:   "always release",
+			policy: AlwaysRelease(),
+			want:   []string{"val.Spans = nil"},
+			avoid:  []string{"[:0]", "cap(", "Reset()"},
+		},
+		{
+			name:    "always release skips the elementwise Reset loop",
+			policy:  AlwaysRelease(),
+			recurse: true,
+			want:    []string{"val.Spans = nil"},
+			avoid:   []string{"[:0]", "cap(", "Reset()"},
+		},
+		{
+			name:   "release above threshold without recursive element",
+			policy: ReleaseAboveThreshold(1024),
+			want:   []string{"cap(val.Spans) > 1024", "val.Spans = nil", "val.Spans = val.Spans[:0]"},
+			avoid:  []string{"Reset()"},
+		},
+		{
+			name:    "release above threshold only resets elements it keeps",
+			policy:  ReleaseAboveThreshold(1024),
+			recurse: true,
+			want:    []string{"cap(val.Spans) > 1024", "val.Spans = nil", "val.Spans[i].Reset()", "val.Spans = val.Spans[:0]"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &resetPlugin{policy: tc.policy}
+			var buf bytes.Buffer
+			p.generateSliceClear(&buf, "Spans", tc.recurse)
+			out := buf.String()
+			for _, want := range tc.want {
+				assert.Contains(t, out, want)
+			}
+			for _, avoid := range tc.avoid {
+				assert.NotContains(t, out, avoid)
+			}
+			if tc.recurse && tc.policy.mode == releaseAboveThreshold {
+				// the elementwise Reset loop must be nested inside the
+				// branch that keeps val.Spans, not run unconditionally
+				// before the nil check decides whether to drop it
+				nilIdx := strings.Index(out, "val.Spans = nil")
+				resetIdx := strings.Index(out, "val.Spans[i].Reset()")
+				assert.Greater(t, resetIdx, nilIdx, "elementwise Reset() must come after the nil branch, inside the else")
+			}
+		})
+	}
+}
+
+// TestResetPluginGenerateEndToEnd drives resetPlugin.Generate against a real
+// structType fixture with a slice of a recursive (customStruct) element,
+// the case TestResetPluginSliceClear's direct-call tests can't cover since
+// "is this element type recursive" is determined by Generate's own lookup
+// call, not passed in by the caller. It guards against the elementwise
+// Reset() loop coming back unconditionally in Generate itself, even though
+// generateSliceClear(..., recurse) is correct in isolation.
+func TestResetPluginGenerateEndToEnd(t *testing.T) {
+	span, spanTyp := newChildFixture("Span")
+	lookup := lookupFixture(span)
+
+	structTyp := structType{
+		name: "Transaction",
+		fields: []structField{
+			newStructField("Spans", types.NewSlice(spanTyp), `json:"spans"`),
+		},
+	}
+
+	for _, tc := range []struct {
+		name            string
+		policy          ResetPolicy
+		wantElementLoop bool
+	}{
+		{name: "retain capacity resets kept elements", policy: RetainCapacity(), wantElementLoop: true},
+		{name: "always release skips the elementwise loop", policy: AlwaysRelease(), wantElementLoop: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &resetPlugin{policy: tc.policy}
+			var buf bytes.Buffer
+			require.NoError(t, p.Generate(&buf, structTyp, "", lookup))
+			out := buf.String()
+
+			src := "package generated\n\ntype Span struct{ Name string }\ntype Transaction struct{ Spans []Span }\n\n" + out
+			_, err := parser.ParseFile(token.NewFileSet(), "reset.go", src, 0)
+			require.NoError(t, err, "generated Reset():\n%s", out)
+
+			if tc.wantElementLoop {
+				assert.Contains(t, out, "val.Spans[i].Reset()")
+			} else {
+				assert.NotContains(t, out, "val.Spans[i].Reset()")
+			}
+		})
+	}
+}
+
+func TestResetPluginMapClear(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		policy ResetPolicy
+		want   []string
+		avoid  []string
+	}{
+		{
+			name:   "retain capacity is the zero value",
+			policy: ResetPolicy{},
+			want:   []string{"for k := range val.Labels", "delete(val.Labels, k)"},
+			avoid:  []string{"nil", "len("},
+		},
+		{
+			name:   "always release",
+			policy: AlwaysRelease(),
+			want:   []string{"val.Labels = nil"},
+			avoid:  []string{"delete(", "len("},
+		},
+		{
+			name:   "release above threshold",
+			policy: ReleaseAboveThreshold(64),
+			want:   []string{"len(val.Labels) > 64", "val.Labels = nil", "delete(val.Labels, k)"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &resetPlugin{policy: tc.policy}
+			var buf bytes.Buffer
+			p.generateMapClear(&buf, "Labels")
+			out := buf.String()
+			for _, want := range tc.want {
+				assert.Contains(t, out, want)
+			}
+			for _, avoid := range tc.avoid {
+				assert.NotContains(t, out, avoid)
+			}
+		})
+	}
+}
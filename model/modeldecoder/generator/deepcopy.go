@@ -0,0 +1,157 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package generator
+
+import (
+	"fmt"
+	"go/types"
+	"io"
+)
+
+// deepCopyPlugin generates Kubernetes-style `DeepCopyInto(out *T)` and
+// `DeepCopy() *T` methods, giving downstream consumers a safe way to
+// snapshot a decoded event for asynchronous processing (e.g. sampling,
+// aggregation) without racing the pooled Reset paths the other plugins
+// generate. Cycles between struct types are guarded by the same
+// processedTypes set CodeGenerator.generate uses, since all plugins run
+// within that same recursion.
+//
+// deepCopyPlugin is opt-in (see NewDeepCopyPlugin); it isn't part of
+// DefaultPlugins since not every caller of CodeGenerator needs the extra
+// generated methods.
+type deepCopyPlugin struct{}
+
+// NewDeepCopyPlugin returns a MethodPlugin generating DeepCopyInto and
+// DeepCopy methods. It is not part of DefaultPlugins; append it to the
+// plugin slice passed to NewCodeGenerator for the types that need it.
+func NewDeepCopyPlugin() MethodPlugin {
+	return &deepCopyPlugin{}
+}
+
+func (p *deepCopyPlugin) Name() string { return "deepcopy" }
+
+func (p *deepCopyPlugin) Generate(w io.Writer, structTyp structType, key string, lookup func(types.Type) (structType, bool)) error {
+	fmt.Fprintf(w, `
+func (val *%s) DeepCopyInto(out *%s) {
+	*out = *val
+`[1:], structTyp.name, structTyp.name)
+
+	for _, f := range structTyp.fields {
+		if !f.Exported() {
+			continue
+		}
+		switch t := f.Type().Underlying().(type) {
+		case *types.Slice:
+			if _, ok := lookup(t.Elem()); ok {
+				fmt.Fprintf(w, `
+	if val.%s != nil {
+		out.%s = make(%s, len(val.%s))
+		for i := range val.%s {
+			val.%s[i].DeepCopyInto(&out.%s[i])
+		}
+	}
+`[1:], f.Name(), f.Name(), f.Type().String(), f.Name(), f.Name(), f.Name(), f.Name())
+			} else {
+				fmt.Fprintf(w, `
+	if val.%s != nil {
+		out.%s = make(%s, len(val.%s))
+		copy(out.%s, val.%s)
+	}
+`[1:], f.Name(), f.Name(), f.Type().String(), f.Name(), f.Name(), f.Name())
+			}
+		case *types.Map:
+			if child, ok := lookup(t.Elem()); ok {
+				fmt.Fprintf(w, `
+	if val.%s != nil {
+		out.%s = make(%s, len(val.%s))
+		for k, v := range val.%s {
+			var vCopy %s
+			v.DeepCopyInto(&vCopy)
+			out.%s[k] = vCopy
+		}
+	}
+`[1:], f.Name(), f.Name(), f.Type().String(), f.Name(), f.Name(), child.name, f.Name())
+			} else {
+				fmt.Fprintf(w, `
+	if val.%s != nil {
+		out.%s = make(%s, len(val.%s))
+		for k, v := range val.%s {
+			out.%s[k] = v
+		}
+	}
+`[1:], f.Name(), f.Name(), f.Type().String(), f.Name(), f.Name(), f.Name())
+			}
+		case *types.Struct:
+			// custom structs (including nested model types) get a real
+			// recursive copy; nullable wrapper types only hold a scalar
+			// and a set-flag, so the `*out = *val` above already copied
+			// them correctly
+			if _, ok := lookup(f.Type()); ok {
+				fmt.Fprintf(w, `
+	val.%s.DeepCopyInto(&out.%s)
+`[1:], f.Name(), f.Name())
+			}
+		case *types.Pointer:
+			_, recurse := lookup(t.Elem())
+			fmt.Fprint(w, deepCopyPointerStmt(f.Name(), t.Elem().String(), recurse))
+		}
+		// other field kinds (strings, ints, bools, ...) are value types
+		// already copied correctly by the `*out = *val` assignment above
+	}
+
+	fmt.Fprint(w, `
+}
+`[1:])
+
+	fmt.Fprintf(w, `
+func (val *%s) DeepCopy() *%s {
+	if val == nil {
+		return nil
+	}
+	out := new(%s)
+	val.DeepCopyInto(out)
+	return out
+}
+`[1:], structTyp.name, structTyp.name, structTyp.name)
+	return nil
+}
+
+// deepCopyPointerStmt returns the statement(s) that copy a pointer field.
+// When the pointee is itself a customStruct (recurse is true), it allocates
+// the target and recurses via DeepCopyInto; otherwise a one-level value
+// copy is correct since the pointee has no further slice/map/pointer fields
+// of its own to alias. Without the recurse case, a pointer to a custom
+// struct would still share that struct's slice/map backing storage with the
+// original, racing its pooled Reset() - the exact problem DeepCopy exists
+// to avoid.
+func deepCopyPointerStmt(field, elemType string, recurse bool) string {
+	if recurse {
+		return fmt.Sprintf(`
+	if val.%s != nil {
+		out.%s = new(%s)
+		val.%s.DeepCopyInto(out.%s)
+	}
+`[1:], field, field, elemType, field, field)
+	}
+	return fmt.Sprintf(`
+	if val.%s != nil {
+		out.%s = new(%s)
+		*out.%s = *val.%s
+	}
+`[1:], field, field, elemType, field, field)
+}
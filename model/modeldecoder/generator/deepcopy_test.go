@@ -0,0 +1,96 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package generator
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeepCopyPointerStmt(t *testing.T) {
+	t.Run("recurses into a custom struct pointee", func(t *testing.T) {
+		stmt := deepCopyPointerStmt("Child", "Child", true)
+		assert.Contains(t, stmt, "out.Child = new(Child)")
+		assert.Contains(t, stmt, "val.Child.DeepCopyInto(out.Child)")
+		assert.NotContains(t, stmt, "*out.Child = *val.Child")
+	})
+
+	t.Run("value-copies a scalar pointee", func(t *testing.T) {
+		stmt := deepCopyPointerStmt("Count", "int", false)
+		assert.Contains(t, stmt, "out.Count = new(int)")
+		assert.Contains(t, stmt, "*out.Count = *val.Count")
+		assert.NotContains(t, stmt, "DeepCopyInto")
+	})
+
+	t.Run("parses as valid Go in context", func(t *testing.T) {
+		for _, recurse := range []bool{true, false} {
+			src := "package generated\n\nfunc (val *T) DeepCopyInto(out *T) {\n" +
+				deepCopyPointerStmt("Child", "Child", recurse) + "}\n"
+			_, err := parser.ParseFile(token.NewFileSet(), "deepcopy.go", src, 0)
+			require.NoError(t, err, "recurse=%v:\n%s", recurse, src)
+		}
+	})
+}
+
+// TestDeepCopyPluginGenerateEndToEnd drives deepCopyPlugin.Generate against a
+// structType fixture covering a slice of a customStruct element, a pointer
+// to a customStruct, and a nullable wrapper struct field - the three field
+// kinds DeepCopyInto special-cases - then type-checks the emitted methods
+// against stub types rather than only parsing them, so a field kind that
+// recurses into a method the stub doesn't have would fail the same way an
+// undefined function reference would.
+func TestDeepCopyPluginGenerateEndToEnd(t *testing.T) {
+	span, spanTyp := newChildFixture("Span")
+	lookup := lookupFixture(span)
+
+	structTyp := structType{
+		name: "Transaction",
+		fields: []structField{
+			newStructField("Spans", types.NewSlice(spanTyp), `json:"spans"`),
+			newStructField("Root", types.NewPointer(spanTyp), `json:"root"`),
+			newStructField("Name", namedType(nullableTypeString), `json:"name"`),
+		},
+	}
+
+	plugin := NewDeepCopyPlugin()
+	var buf bytes.Buffer
+	require.NoError(t, plugin.Generate(&buf, structTyp, "", lookup))
+	out := buf.String()
+
+	assert.Contains(t, out, "val.Spans[i].DeepCopyInto(&out.Spans[i])")
+	assert.Contains(t, out, "val.Root.DeepCopyInto(out.Root)")
+	assert.NotContains(t, out, "val.Name.DeepCopyInto", "nullable wrapper fields are already copied by *out = *val")
+
+	src := "package generated\n\ntype Span struct {\n\tName string\n}\n\n" +
+		"func (val *Span) DeepCopyInto(out *Span) { *out = *val }\n\n" +
+		"type NullableString struct {\n\tval string\n\tset bool\n}\n\n" +
+		"type Transaction struct {\n\tSpans []Span\n\tRoot *Span\n\tName NullableString\n}\n\n" + out
+
+	checkGeneratedSourceCompiles(t, src)
+}
+
+func TestNewDeepCopyPluginName(t *testing.T) {
+	plugin := NewDeepCopyPlugin()
+	assert.Equal(t, "deepcopy", plugin.Name())
+}
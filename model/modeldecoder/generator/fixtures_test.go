@@ -0,0 +1,109 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package generator
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newStructField builds a structField the way the parser builds one from an
+// *ast.Field: a *types.Var carrying the name, type and exported-ness the
+// plugins switch on, plus the raw struct tag parseTag reads `json`/`validate`
+// constraints from. Shared by the plugin fixture tests below so each one can
+// drive a real Generate() call instead of only exercising extracted helpers.
+func newStructField(name string, typ types.Type, tag string) structField {
+	return structField{
+		Var: types.NewVar(token.NoPos, nil, name, typ),
+		tag: reflect.StructTag(tag),
+	}
+}
+
+// namedType returns a types.Named whose String() is exactly qualifiedName
+// (e.g. nullableTypeString), so a fixture field can exercise the
+// f.Type().String() dispatch in generateFieldCase/fieldSchema/
+// validationPlugin.Generate without this test package importing the
+// concrete nullable/model types it isn't able to reach.
+func namedType(qualifiedName string) *types.Named {
+	i := strings.LastIndex(qualifiedName, ".")
+	pkgPath, name := qualifiedName[:i], qualifiedName[i+1:]
+	pkg := types.NewPackage(pkgPath, name)
+	obj := types.NewTypeName(token.NoPos, pkg, name, nil)
+	return types.NewNamed(obj, types.NewStruct(nil, nil), nil)
+}
+
+// newChildFixture builds a minimal customStruct usable as a slice/map
+// element or nested struct field in the other fixtures: a single exported
+// string field, registered under its own *types.Named so lookup() resolves
+// it. Unlike namedType (used for the nullable wrapper types, which live in
+// a separate imported package), this Named has no package, the same way a
+// sibling type declared in the very package being generated has no
+// qualifier when the generator stringifies it for e.g. make(%s, ...) - so
+// the fixture's emitted source references it as the bare "Span", not an
+// unresolvable "pkgpath.Span".
+func newChildFixture(name string) (structType, *types.Named) {
+	obj := types.NewTypeName(token.NoPos, nil, name, nil)
+	typ := types.NewNamed(obj, types.NewStruct(nil, nil), nil)
+	st := structType{
+		name:   name,
+		fields: []structField{newStructField("Name", types.Typ[types.String], `json:"name"`)},
+	}
+	return st, typ
+}
+
+// lookupFixture returns a lookup func backed by a small set of customStructs,
+// matching the signature CodeGenerator.customStruct/SchemaGenerator.customStruct
+// pass to plugins.
+func lookupFixture(structs ...structType) func(types.Type) (structType, bool) {
+	byName := make(map[string]structType, len(structs))
+	for _, st := range structs {
+		byName[st.name] = st
+	}
+	return func(t types.Type) (structType, bool) {
+		named, ok := t.(*types.Named)
+		if !ok {
+			return structType{}, false
+		}
+		st, ok := byName[named.Obj().Name()]
+		return st, ok
+	}
+}
+
+// checkGeneratedSourceCompiles parses and type-checks src as a standalone
+// package, failing the test if any identifier or call doesn't resolve. This
+// goes further than parser.ParseFile alone: a call to an undefined function
+// still parses as valid Go syntax (parsing doesn't resolve names), which is
+// exactly how 29b6ef6 shipped decodeJSON methods calling
+// decodeNullableString/decodeNullableInt/... that didn't exist anywhere.
+func checkGeneratedSourceCompiles(t *testing.T, src string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "generated.go", src, 0)
+	require.NoError(t, err, "generated source does not parse:\n%s", src)
+	conf := types.Config{Importer: importer.Default()}
+	_, err = conf.Check("generated", fset, []*ast.File{f}, nil)
+	require.NoError(t, err, "generated source does not type-check:\n%s", src)
+}
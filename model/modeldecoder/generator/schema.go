@@ -0,0 +1,291 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is a minimal representation of the subset of JSON Schema
+// (Draft 2020-12) that SchemaGenerator emits; it mirrors the constraints
+// CodeGenerator's validate() methods enforce rather than the full spec.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+}
+
+// SchemaGenerator emits a JSON Schema document per root type, derived from
+// the same Parsed metadata and rootObjs set CodeGenerator uses to emit
+// IsSet, Reset and validate methods. The generated schemas describe the same
+// constraints validate() enforces (required-ness, patterns, min/max, enums,
+// nested object shapes) so operators have a machine-readable contract for
+// the intake event schema without the constraints living in two disagreeing
+// places.
+type SchemaGenerator struct {
+	parsed   *Parsed
+	rootObjs []structType
+
+	// keep track of already processed types in case one type is
+	// referenced multiple times, mirroring CodeGenerator.processedTypes
+	processedTypes map[string]*jsonSchema
+}
+
+// NewSchemaGenerator takes the parsed type definitions and the root types
+// for which a schema should be generated. The generator produces a schema
+// only for types referenced directly or indirectly by any of the root
+// types.
+func NewSchemaGenerator(parsed *Parsed, rootTypes []string) (*SchemaGenerator, error) {
+	g := SchemaGenerator{
+		parsed:         parsed,
+		rootObjs:       make([]structType, len(rootTypes)),
+		processedTypes: make(map[string]*jsonSchema),
+	}
+	for i := 0; i < len(rootTypes); i++ {
+		rootStruct, ok := parsed.structTypes[rootTypes[i]]
+		if !ok {
+			return nil, fmt.Errorf("object with root key %s not found", rootTypes[i])
+		}
+		g.rootObjs[i] = rootStruct
+	}
+	return &g, nil
+}
+
+// Generate writes a JSON object, keyed by root type name, whose values are
+// the Draft 2020-12 JSON Schema document for that root type, to w.
+func (g *SchemaGenerator) Generate(w io.Writer) error {
+	out := make(map[string]*jsonSchema, len(g.rootObjs))
+	for _, rootObj := range g.rootObjs {
+		schema, err := g.generate(rootObj)
+		if err != nil {
+			return errors.Wrap(err, "schema generator")
+		}
+		out[rootObj.name] = schema
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// generate builds the schema for st, recursing into nested custom struct
+// fields the same way CodeGenerator.generate walks them. Types already
+// resolved are returned from the cache rather than reprocessed, guarding
+// against cycles between struct types.
+func (g *SchemaGenerator) generate(st structType) (*jsonSchema, error) {
+	if schema, ok := g.processedTypes[st.name]; ok {
+		return schema, nil
+	}
+	schema := &jsonSchema{
+		Type:       "object",
+		Properties: make(map[string]*jsonSchema),
+	}
+	for _, rootObj := range g.rootObjs {
+		if st.name == rootObj.name {
+			schema.Schema = jsonSchemaDialect
+			break
+		}
+	}
+	// register before recursing so a cycle back to st resolves to the
+	// same (possibly still being populated) schema instead of looping
+	g.processedTypes[st.name] = schema
+
+	var required []string
+	for _, f := range st.fields {
+		if !f.Exported() {
+			continue
+		}
+		tagConstraints := parseTag(f.tag, "validate")
+		fieldSchema, err := g.fieldSchema(f, tagConstraints)
+		if err != nil {
+			return nil, errors.Wrap(err, flattenName("", f))
+		}
+		schema.Properties[jsonName(f)] = fieldSchema
+		if fieldRequired(tagConstraints) {
+			required = append(required, jsonName(f))
+		}
+	}
+	schema.Required = required
+	return schema, nil
+}
+
+// fieldRequired reports whether a field's `validate` tag marks it required.
+// This is the same notion of required-ness IsSet()/validate() use elsewhere
+// in this package: slices, maps and nested structs are optional unless their
+// tag says otherwise (validate() on a non-root type returns immediately when
+// !val.IsSet()), so required-ness can't be derived from the field's type
+// alone - only nullable-wrapper-ness would, and every slice/map/struct field
+// would wrongly end up required.
+func fieldRequired(constraints []string) bool {
+	for _, c := range constraints {
+		if c == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldSchema derives the schema for a single struct field, translating the
+// same signals validationPlugin.Generate switches on: validation tags for
+// patterns/min/max/enum/required, and nested custom structs for object/array
+// shapes.
+func (g *SchemaGenerator) fieldSchema(f structField, tagConstraints []string) (*jsonSchema, error) {
+	switch f.Type().String() {
+	case nullableTypeString:
+		s := &jsonSchema{Type: "string"}
+		g.applyStringConstraints(s, tagConstraints)
+		return s, nil
+	case nullableTypeInt, nullableTypeFloat64:
+		s := &jsonSchema{Type: "number"}
+		applyNumericConstraints(s, tagConstraints)
+		return s, nil
+	case nullableTypeInterface:
+		return &jsonSchema{}, nil
+	}
+
+	switch t := f.Type().Underlying().(type) {
+	case *types.Slice:
+		items := &jsonSchema{Type: basicJSONType(t.Elem())}
+		if child, ok := g.customStruct(t.Elem()); ok {
+			childSchema, err := g.generate(child)
+			if err != nil {
+				return nil, err
+			}
+			items = childSchema
+		}
+		return &jsonSchema{Type: "array", Items: items}, nil
+	case *types.Map:
+		// map keys are dynamic, so the value shape can't be expressed as
+		// named properties; additionalProperties is left true regardless
+		// of whether the value type is a custom struct
+		additional := true
+		return &jsonSchema{Type: "object", AdditionalProperties: &additional}, nil
+	case *types.Struct:
+		child, ok := g.customStruct(f.Type())
+		if !ok {
+			return nil, fmt.Errorf("unhandled struct type for %s", jsonName(f))
+		}
+		childSchema, err := g.generate(child)
+		if err != nil {
+			return nil, err
+		}
+		return childSchema, nil
+	default:
+		return nil, fmt.Errorf("unhandled type %T for %s", t, jsonName(f))
+	}
+}
+
+// basicJSONType maps a Go basic kind to the JSON Schema `type` keyword that
+// describes it, for slice elements that aren't a customStruct. Types whose
+// underlying kind isn't a recognized basic kind (e.g. an unexported alias)
+// fall back to "string" rather than failing generation outright.
+func basicJSONType(t types.Type) string {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return "string"
+	}
+	switch {
+	case basic.Info()&types.IsBoolean != 0:
+		return "boolean"
+	case basic.Info()&types.IsInteger != 0:
+		return "integer"
+	case basic.Info()&types.IsFloat != 0:
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// applyStringConstraints translates `validate` tag entries such as
+// `pattern=fooRegexp` and `enum=a|b|c` into the matching JSON Schema
+// keywords; entries it doesn't recognize are left for the generated
+// validate() methods to enforce at decode time.
+func (g *SchemaGenerator) applyStringConstraints(s *jsonSchema, constraints []string) {
+	for _, c := range constraints {
+		k, v, ok := splitConstraint(c)
+		if !ok {
+			continue
+		}
+		switch k {
+		case "pattern":
+			if re, ok := g.patternVariableRegexp(v); ok {
+				s.Pattern = re
+			}
+		case "enum":
+			s.Enum = strings.Split(v, "|")
+		}
+	}
+}
+
+// applyNumericConstraints translates `validate` tag entries such as
+// `min=0` and `max=65535` into the matching JSON Schema keywords.
+func applyNumericConstraints(s *jsonSchema, constraints []string) {
+	for _, c := range constraints {
+		k, v, ok := splitConstraint(c)
+		if !ok {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			continue
+		}
+		switch k {
+		case "min":
+			s.Minimum = &f
+		case "max":
+			s.Maximum = &f
+		}
+	}
+}
+
+func splitConstraint(constraint string) (key, value string, ok bool) {
+	parts := strings.SplitN(constraint, "=", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// patternVariableRegexp looks up the value Parsed.patternVariables records
+// for the given pattern variable name, the variable CodeGenerator.Generate()
+// compiles as `<name>Regexp = regexp.MustCompile(<name>)`, so the schema's
+// `pattern` keyword matches validate()'s regexp.
+func (g *SchemaGenerator) patternVariableRegexp(name string) (string, bool) {
+	re, ok := g.parsed.patternVariables[name]
+	return re, ok
+}
+
+func (g *SchemaGenerator) customStruct(typ types.Type) (t structType, ok bool) {
+	t, ok = g.parsed.structTypes[typ.String()]
+	return
+}
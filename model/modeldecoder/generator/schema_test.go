@@ -0,0 +1,137 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldRequired(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		constraints []string
+		want        bool
+	}{
+		{name: "no constraints", constraints: nil, want: false},
+		{name: "unrelated constraints", constraints: []string{"max=10", "pattern=fooRegexp"}, want: false},
+		{name: "required present", constraints: []string{"required"}, want: true},
+		{name: "required alongside others", constraints: []string{"max=10", "required"}, want: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, fieldRequired(tc.constraints))
+		})
+	}
+}
+
+func TestBasicJSONType(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		typ  types.Type
+		want string
+	}{
+		{name: "string", typ: types.Typ[types.String], want: "string"},
+		{name: "bool", typ: types.Typ[types.Bool], want: "boolean"},
+		{name: "int64", typ: types.Typ[types.Int64], want: "integer"},
+		{name: "float64", typ: types.Typ[types.Float64], want: "number"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, basicJSONType(tc.typ))
+		})
+	}
+}
+
+func TestSplitConstraint(t *testing.T) {
+	k, v, ok := splitConstraint("max=1024")
+	assert.True(t, ok)
+	assert.Equal(t, "max", k)
+	assert.Equal(t, "1024", v)
+
+	_, _, ok = splitConstraint("required")
+	assert.False(t, ok)
+}
+
+// TestSchemaGeneratorGenerateEndToEnd drives SchemaGenerator.Generate against
+// a real structType/Parsed fixture with a required nullable string field and
+// a slice of a nested customStruct, exercising the recursive generate() path
+// rather than only fieldSchema's/fieldRequired's leaf logic in isolation.
+func TestSchemaGeneratorGenerateEndToEnd(t *testing.T) {
+	span, spanTyp := newChildFixture("Span")
+	// fieldSchema, like validationPlugin, only handles nullable wrapper,
+	// slice, map and struct fields, so the nested Span uses a nullable string
+	span.fields = []structField{newStructField("Name", namedType(nullableTypeString), `json:"name"`)}
+
+	root := structType{
+		name: "Transaction",
+		fields: []structField{
+			newStructField("Name", namedType(nullableTypeString), `json:"name" validate:"required,enum=a|b"`),
+			newStructField("Spans", types.NewSlice(spanTyp), `json:"spans"`),
+		},
+	}
+
+	parsed := &Parsed{
+		pkgName: "generatortest",
+		structTypes: map[string]structType{
+			root.name:        root,
+			spanTyp.String(): span,
+		},
+	}
+
+	g, err := NewSchemaGenerator(parsed, []string{root.name})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, g.Generate(&buf))
+
+	var out map[string]*jsonSchema
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+
+	txn, ok := out["Transaction"]
+	require.True(t, ok, "missing schema for root type Transaction")
+	assert.Equal(t, jsonSchemaDialect, txn.Schema)
+	assert.Equal(t, []string{"name"}, txn.Required)
+
+	nameSchema, ok := txn.Properties["name"]
+	require.True(t, ok)
+	assert.Equal(t, "string", nameSchema.Type)
+	assert.Equal(t, []string{"a", "b"}, nameSchema.Enum)
+
+	spansSchema, ok := txn.Properties["spans"]
+	require.True(t, ok)
+	assert.Equal(t, "array", spansSchema.Type)
+	require.NotNil(t, spansSchema.Items)
+	assert.Equal(t, "object", spansSchema.Items.Type)
+	_, ok = spansSchema.Items.Properties["name"]
+	assert.True(t, ok, "nested Span schema should have recursed into its own fields")
+}
+
+func TestApplyNumericConstraints(t *testing.T) {
+	s := &jsonSchema{}
+	applyNumericConstraints(s, []string{"min=0", "max=65535", "notanumber=x"})
+	if assert.NotNil(t, s.Minimum) {
+		assert.Equal(t, float64(0), *s.Minimum)
+	}
+	if assert.NotNil(t, s.Maximum) {
+		assert.Equal(t, float64(65535), *s.Maximum)
+	}
+}
@@ -0,0 +1,201 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package generator
+
+import (
+	"fmt"
+	"go/types"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// unmarshalRuntimeSupport is emitted once into the preamble of any generated
+// file that uses unmarshalJSONPlugin (see CodeGenerator.hasUnmarshalJSONPlugin).
+// It implements the object/array delimiter and key reads decodeJSON needs
+// purely in terms of encoding/json.Decoder.Token, so the generated file has
+// no dependency beyond the standard library.
+const unmarshalRuntimeSupport = `
+func decodeDelim(dec *json.Decoder, want rune) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || rune(d) != want {
+		return fmt.Errorf("expected delimiter %q, got %v", want, t)
+	}
+	return nil
+}
+
+func decodeObjectKey(dec *json.Decoder) (string, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := t.(string)
+	if !ok {
+		return "", fmt.Errorf("expected object key, got %v", t)
+	}
+	return key, nil
+}
+
+// decodeNullable reads one JSON value from dec and hands it directly to
+// out's own UnmarshalJSON. It exists so a nullable field's generated case
+// can dispatch to that method without going through dec.Decode(&val.Field),
+// which has to reflect over &val.Field first to discover it implements
+// json.Unmarshaler; calling out.UnmarshalJSON through the interface here
+// skips that per-field reflection.
+func decodeNullable(dec *json.Decoder, out json.Unmarshaler) error {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	return out.UnmarshalJSON(raw)
+}
+`[1:]
+
+// unmarshalJSONPlugin generates `UnmarshalJSON` and a streaming `decodeJSON`
+// method per struct, switching over field names with a generated string
+// table instead of going through reflection-based encoding/json. Fields
+// backed by a customStruct (directly, or as a slice element) recurse into
+// the nested type's own decodeJSON the way CodeGenerator.generate recurses
+// to emit methods for them; the nullable wrapper types dispatch to
+// decodeNullable, mirroring how validationPlugin dispatches them to typed
+// validation helpers rather than falling through to a generic case. All
+// other fields (maps, slices of non-model elements, other structs and
+// scalars) fall back to the standard library's `dec.Decode`.
+//
+// unmarshalJSONPlugin is opt-in (see NewUnmarshalJSONPlugin): the intake
+// server already hand-writes decoders for some root types today, and
+// generating a second UnmarshalJSON/decodeJSON pair for those types would
+// collide with the existing method.
+type unmarshalJSONPlugin struct {
+	ctx *PluginContext
+}
+
+// NewUnmarshalJSONPlugin returns a MethodPlugin generating UnmarshalJSON and
+// decodeJSON methods. It is not part of DefaultPlugins; pass it explicitly
+// to NewCodeGenerator (or append it to DefaultPlugins) for the types that
+// don't already have a hand-written decoder.
+func NewUnmarshalJSONPlugin(ctx *PluginContext) MethodPlugin {
+	return &unmarshalJSONPlugin{ctx: ctx}
+}
+
+func (p *unmarshalJSONPlugin) Name() string { return "unmarshaljson" }
+
+func (p *unmarshalJSONPlugin) Generate(w io.Writer, structTyp structType, key string, lookup func(types.Type) (structType, bool)) error {
+	fmt.Fprintf(w, `
+func (val *%s) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	return val.decodeJSON(dec)
+}
+
+func (val *%s) decodeJSON(dec *json.Decoder) error {
+	if err := decodeDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := decodeObjectKey(dec)
+		if err != nil {
+			return err
+		}
+		switch key {
+`[1:], structTyp.name, structTyp.name)
+
+	for _, f := range structTyp.fields {
+		if !f.Exported() {
+			continue
+		}
+		if err := p.generateFieldCase(w, f, lookup); err != nil {
+			return errors.Wrap(err, flattenName(key, f))
+		}
+	}
+
+	fmt.Fprint(w, `
+		default:
+			if err := dec.Decode(new(json.RawMessage)); err != nil {
+				return err
+			}
+		}
+	}
+	return decodeDelim(dec, '}')
+}
+`[1:])
+	return nil
+}
+
+// generateFieldCase writes the `case "<json name>":` branch that decodes a
+// single field. Slices of a customStruct element recurse into the element's
+// own decodeJSON; a directly nested customStruct recurses into its
+// decodeJSON too. Nullable wrapper types dispatch to decodeNullable, the
+// same field-type switch validationPlugin.Generate uses to route them to
+// generateNullableStringValidation et al. Everything else - maps, slices of
+// non-model elements, other structs and plain scalars - is decoded with
+// dec.Decode, the same json.Decoder method the rest of decodeJSON is built on.
+func (p *unmarshalJSONPlugin) generateFieldCase(w io.Writer, f structField, lookup func(types.Type) (structType, bool)) error {
+	fmt.Fprintf(w, `
+		case "%s":
+`[1:], p.ctx.JSONName(f))
+
+	switch f.Type().String() {
+	case nullableTypeString, nullableTypeInt, nullableTypeFloat64, nullableTypeInterface:
+		fmt.Fprintf(w, `
+			if err := decodeNullable(dec, &val.%s); err != nil {
+				return err
+			}
+`[1:], f.Name())
+		return nil
+	}
+
+	switch t := f.Type().Underlying().(type) {
+	case *types.Slice:
+		if child, ok := lookup(t.Elem()); ok {
+			fmt.Fprintf(w, `
+			if err := decodeDelim(dec, '['); err != nil {
+				return err
+			}
+			for dec.More() {
+				val.%s = append(val.%s, %s{})
+				if err := val.%s[len(val.%s)-1].decodeJSON(dec); err != nil {
+					return err
+				}
+			}
+			if err := decodeDelim(dec, ']'); err != nil {
+				return err
+			}
+`[1:], f.Name(), f.Name(), child.name, f.Name(), f.Name())
+			return nil
+		}
+	case *types.Struct:
+		if _, ok := lookup(f.Type()); ok {
+			fmt.Fprintf(w, `
+			if err := val.%s.decodeJSON(dec); err != nil {
+				return err
+			}
+`[1:], f.Name())
+			return nil
+		}
+	}
+
+	fmt.Fprintf(w, `
+			if err := dec.Decode(&val.%s); err != nil {
+				return err
+			}
+`[1:], f.Name())
+	return nil
+}
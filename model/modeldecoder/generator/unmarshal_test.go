@@ -0,0 +1,108 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package generator
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUnmarshalRuntimeSupportParses is a golden-style check that the
+// hand-written decodeJSON support code emitted into generated files is
+// itself syntactically valid Go, catching exactly the class of "generated
+// code doesn't compile" bug a typo in this template would otherwise only
+// surface downstream, in a generated package we can't unit test directly.
+func TestUnmarshalRuntimeSupportParses(t *testing.T) {
+	src := "package generated\n\nimport (\n\t\"encoding/json\"\n\t\"fmt\"\n)\n" + unmarshalRuntimeSupport
+	_, err := parser.ParseFile(token.NewFileSet(), "unmarshal_support.go", src, 0)
+	require.NoError(t, err)
+}
+
+func TestNewUnmarshalJSONPluginName(t *testing.T) {
+	plugin := NewUnmarshalJSONPlugin(NewPluginContext())
+	assert.Equal(t, "unmarshaljson", plugin.Name())
+}
+
+// TestUnmarshalJSONPluginGenerateEndToEnd drives unmarshalJSONPlugin.Generate
+// against a real structType fixture with a nullable string field and a slice
+// of a customStruct element, then type-checks the emitted decodeJSON/
+// UnmarshalJSON methods against a standalone stub package - not just
+// checking that they parse as Go, but that every call they make (including
+// the typed decodeNullable dispatch) resolves against a concrete
+// implementation. This is the check that would have caught 29b6ef6 calling
+// nonexistent decodeNullableString/decodeNullableInt/... functions: a
+// template that references undefined identifiers inside a function body
+// still parses, because parsing doesn't resolve names.
+func TestUnmarshalJSONPluginGenerateEndToEnd(t *testing.T) {
+	span, spanTyp := newChildFixture("Span")
+	lookup := lookupFixture(span)
+
+	structTyp := structType{
+		name: "Transaction",
+		fields: []structField{
+			newStructField("Name", namedType(nullableTypeString), `json:"name"`),
+			newStructField("Spans", types.NewSlice(spanTyp), `json:"spans"`),
+		},
+	}
+
+	plugin := NewUnmarshalJSONPlugin(NewPluginContext())
+	var buf bytes.Buffer
+	require.NoError(t, plugin.Generate(&buf, structTyp, "", lookup))
+	out := buf.String()
+
+	assert.Contains(t, out, `if err := decodeNullable(dec, &val.Name); err != nil {`,
+		"nullable fields must dispatch to the typed decodeNullable helper, not a generic dec.Decode")
+	assert.Contains(t, out, "val.Spans[len(val.Spans)-1].decodeJSON(dec)")
+
+	src := "package generated\n\nimport (\n\t\"bytes\"\n\t\"encoding/json\"\n\t\"fmt\"\n)\n\n" +
+		unmarshalRuntimeSupport + `
+type NullableString struct {
+	val string
+	set bool
+}
+
+func (n *NullableString) UnmarshalJSON(data []byte) error {
+	n.set = true
+	return json.Unmarshal(data, &n.val)
+}
+
+type Span struct {
+	Name string
+}
+
+func (val *Span) decodeJSON(dec *json.Decoder) error {
+	if err := decodeDelim(dec, '{'); err != nil {
+		return err
+	}
+	return decodeDelim(dec, '}')
+}
+
+type Transaction struct {
+	Name  NullableString
+	Spans []Span
+}
+` + out
+
+	checkGeneratedSourceCompiles(t, src)
+}